@@ -0,0 +1,10 @@
+package main
+
+// repoBackend abstracts the way branch, ref and commit data is read from the
+// repository so main can be agnostic to whether that happens by shelling out
+// to git or through an embedded implementation such as go-git.
+type repoBackend interface {
+	Branches() ([]Branch, error)
+	MainBranch() (string, error)
+	Log(ref string, max int) ([]Commit, error)
+}
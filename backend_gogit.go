@@ -0,0 +1,118 @@
+//go:build gogit
+
+package main
+
+import (
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// goGitBackend implements repoBackend on top of go-git, opening the
+// repository's object database once and reusing it across every branch
+// instead of forking a git process per call.
+type goGitBackend struct {
+	repo *git.Repository
+}
+
+func newRepoBackend() (repoBackend, error) {
+	repo, err := git.PlainOpen(".")
+	if err != nil {
+		return nil, err
+	}
+	return &goGitBackend{repo: repo}, nil
+}
+
+func (b *goGitBackend) Branches() ([]Branch, error) {
+	head, err := b.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := b.repo.Branches()
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	branches := []Branch{}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		branches = append(branches, Branch{
+			Name:    ref.Name().Short(),
+			Current: ref.Name() == head.Name(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return branches, nil
+}
+
+func (b *goGitBackend) MainBranch() (string, error) {
+	remotes, err := b.repo.Remotes()
+	if err != nil {
+		return "", err
+	}
+	if len(remotes) < 1 {
+		cfg, err := b.repo.Config()
+		if err != nil || cfg.Init.DefaultBranch == "" {
+			return "master", nil
+		}
+		return cfg.Init.DefaultBranch, nil
+	}
+
+	headName := plumbing.NewRemoteHEADReferenceName(remotes[0].Config().Name)
+	ref, err := b.repo.Reference(headName, false)
+	if err != nil {
+		return "", err
+	}
+	return ref.Target().Short(), nil
+}
+
+func (b *goGitBackend) Log(ref string, max int) ([]Commit, error) {
+	hash, err := b.repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := b.repo.Log(&git.LogOptions{From: *hash, Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	commits := []Commit{}
+	err = iter.ForEach(func(c *object.Commit) error {
+		if len(commits) >= max {
+			return storer.ErrStop
+		}
+		parents := make([]Hash, len(c.ParentHashes))
+		for i, p := range c.ParentHashes {
+			parents[i] = Hash(p.String())
+		}
+		commits = append(commits, Commit{
+			Hash:    Hash(c.Hash.String()),
+			Parents: parents,
+			Author:  c.Author.Name,
+			Subject: firstLine(c.Message),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+func firstLine(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			return s[:i]
+		}
+	}
+	return s
+}
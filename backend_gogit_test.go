@@ -0,0 +1,101 @@
+//go:build gogit
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// runGit runs git in dir and fails the test on error, mirroring the plumbing
+// the shellBackend itself relies on. Duplicated from main_test.go since that
+// file is built only under !gogit.
+func runGit(t testing.TB, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func commitFile(t testing.TB, dir, name, subject string) {
+	t.Helper()
+	if err := os.WriteFile(dir+"/"+name, []byte(subject), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", name)
+	runGit(t, dir, "commit", "-m", subject)
+}
+
+// TestGoGitBackendMatchesScriptedRepo exercises newRepoBackend's gogit-tagged
+// constructor against a scripted repo with a merge, checking Branches,
+// MainBranch and Log agree with what the shellBackend would report for the
+// same repo.
+func TestGoGitBackendMatchesScriptedRepo(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	runGit(t, dir, "config", "init.defaultBranch", "main")
+	commitFile(t, dir, "base.txt", "base commit")
+
+	runGit(t, dir, "checkout", "-q", "-b", "feature")
+	commitFile(t, dir, "feature.txt", "feature commit")
+
+	runGit(t, dir, "checkout", "-q", "main")
+	runGit(t, dir, "merge", "-q", "--no-ff", "-m", "merge commit", "feature")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	backend, err := newRepoBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mainBranchName, err := backend.MainBranch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mainBranchName != "main" {
+		t.Errorf("expected main branch %q, got %q", "main", mainBranchName)
+	}
+
+	branches, err := backend.Branches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, b := range branches {
+		names[b.Name] = true
+	}
+	if !names["main"] || !names["feature"] {
+		t.Fatalf("expected branches main and feature, got %v", branches)
+	}
+
+	commits, err := backend.Log(mainBranchName, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) == 0 {
+		t.Fatal("expected at least one commit on main")
+	}
+
+	tip := commits[0]
+	if tip.Subject != "merge commit" {
+		t.Errorf("expected tip subject %q, got %q", "merge commit", tip.Subject)
+	}
+	if len(tip.Parents) != 2 {
+		t.Errorf("expected merge commit to have 2 parents, got %d", len(tip.Parents))
+	}
+}
@@ -0,0 +1,111 @@
+//go:build !gogit
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// shellBackend implements repoBackend by shelling out to the git binary on
+// PATH. It is the default backend since it requires no extra dependencies
+// and matches whatever git version the user has installed.
+type shellBackend struct{}
+
+func newRepoBackend() (repoBackend, error) {
+	return shellBackend{}, nil
+}
+
+func git(arg ...string) ([]string, error) {
+	buf := &bytes.Buffer{}
+	cmd := exec.Command("git", arg...)
+	cmd.Stdout = buf
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "'git %v' failed", strings.Join(arg, " "))
+	}
+
+	lines := []string{}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+func (shellBackend) Branches() ([]Branch, error) {
+	lines, err := git("branch")
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make([]Branch, 0, len(lines))
+	for _, line := range lines {
+		name := line[2:]
+		if strings.HasPrefix(name, "(HEAD detached at") {
+			continue
+		}
+		branches = append(branches, Branch{
+			Name:    name,
+			Current: line[0] == '*',
+		})
+	}
+
+	return branches, nil
+}
+
+func (shellBackend) MainBranch() (string, error) {
+	lines, err := git("remote")
+	if err != nil {
+		return "", err
+	}
+	if len(lines) < 1 {
+		defBranchLines, err := git("config", "--get", "init.defaultBranch")
+		if err != nil {
+			return "master", nil
+		}
+		if len(defBranchLines) != 1 {
+			return "master", nil
+		}
+		return strings.TrimSpace(defBranchLines[0]), nil
+	}
+	symRefLines, err := git("symbolic-ref", fmt.Sprintf("refs/remotes/%s/HEAD", lines[0]))
+	if err != nil {
+		return "", err
+	}
+	if len(symRefLines) != 1 {
+		return "", fmt.Errorf("expected one line for symbolic-ref for remote %v", lines[0])
+	}
+	lastSlash := strings.LastIndexByte(symRefLines[0], '/')
+	return symRefLines[0][lastSlash+1:], nil
+}
+
+func (shellBackend) Log(ref string, max int) ([]Commit, error) {
+	lines, err := git("log", "--pretty=format:%H#%P#%an#%s", fmt.Sprintf("--max-count=%d", max), ref, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make([]Commit, len(lines))
+	for i, line := range lines {
+		parts := strings.SplitN(line, "#", 4)
+		commits[i].Hash = Hash(parts[0])
+		if parts[1] != "" {
+			for _, parent := range strings.Split(parts[1], " ") {
+				commits[i].Parents = append(commits[i].Parents, Hash(parent))
+			}
+		}
+		commits[i].Author = parts[2]
+		commits[i].Subject = parts[3]
+	}
+	return commits, nil
+}
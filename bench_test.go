@@ -0,0 +1,58 @@
+//go:build !gogit
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// buildSyntheticRepo creates a repo with a main branch and branchCount
+// branches, each carrying one commit off main, to exercise the branch
+// scanning loop at scale.
+func buildSyntheticRepo(tb testing.TB, branchCount int) string {
+	tb.Helper()
+	dir := tb.TempDir()
+	runGit(tb, dir, "init", "-q", "-b", "main")
+	commitFile(tb, dir, "base.txt", "base commit")
+
+	for i := 0; i < branchCount; i++ {
+		name := fmt.Sprintf("branch-%03d", i)
+		runGit(tb, dir, "checkout", "-q", "-b", name, "main")
+		commitFile(tb, dir, fmt.Sprintf("file-%03d.txt", i), fmt.Sprintf("commit on %s", name))
+	}
+	runGit(tb, dir, "checkout", "-q", "main")
+
+	return dir
+}
+
+// BenchmarkMergeBranchLogs demonstrates the speedup of scanning branches
+// through the worker pool vs. a single in-flight git call at a time
+// (pass -jobs=1 via `go test -bench . -cpu 1` or set jobs manually to
+// compare against the concurrent default).
+func BenchmarkMergeBranchLogs(b *testing.B) {
+	dir := buildSyntheticRepo(b, 50)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		b.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	backend := shellBackend{}
+	branches, err := backend.Branches()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := mergeBranchLogs(backend, "main", branches, nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
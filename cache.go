@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const cacheFileName = "branch-tree-cache"
+
+// cacheEntry is what's persisted to disk between runs: the branch tip
+// fingerprint the cached data was built from, the raw per-branch commit log
+// that fingerprint produced, and the stitched tree that came out of it.
+// Keeping the raw logs lets a later run reuse the branches whose tip didn't
+// move instead of rebuilding the whole graph from scratch.
+type cacheEntry struct {
+	Fingerprint map[string]string
+	Logs        map[string][]Commit
+	Tree        *CommitNode
+}
+
+// cachePath returns the path of the on-disk cache file, rooted under the
+// repository's git directory so it doesn't leak into the worktree.
+func cachePath() (string, error) {
+	lines, err := runGitLines("rev-parse", "--git-dir")
+	if err != nil {
+		return "", err
+	}
+	if len(lines) != 1 {
+		return "", fmt.Errorf("expected one line from 'git rev-parse --git-dir', got %d", len(lines))
+	}
+	return filepath.Join(lines[0], cacheFileName), nil
+}
+
+// branchFingerprint returns the tip commit hash of every local branch,
+// keyed by branch name. Comparing two fingerprints is how we detect whether
+// a cached tree is still valid, and which branches changed if it isn't.
+func branchFingerprint() (map[string]string, error) {
+	lines, err := runGitLines("for-each-ref", "--format=%(refname:short) %(objectname)", "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := make(map[string]string, len(lines))
+	for _, line := range lines {
+		name, hash, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		fingerprint[name] = hash
+	}
+	return fingerprint, nil
+}
+
+func fingerprintsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for branch, hash := range a {
+		if b[branch] != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// loadCache reads a previously saved cacheEntry. A missing or corrupt cache
+// file is not an error: the caller just rebuilds from scratch.
+func loadCache(path string) (*cacheEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entry cacheEntry
+	if err := gob.NewDecoder(f).Decode(&entry); err != nil {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func saveCache(path string, entry *cacheEntry) error {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(entry); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// runGitLines shells out to git directly rather than going through a
+// repoBackend, since fingerprinting needs to stay a single cheap call
+// regardless of which backend build tag is in effect.
+func runGitLines(arg ...string) ([]string, error) {
+	buf := &bytes.Buffer{}
+	cmd := exec.Command("git", arg...)
+	cmd.Stdout = buf
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("'git %v' failed: %w", strings.Join(arg, " "), err)
+	}
+
+	lines := []string{}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
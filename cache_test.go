@@ -0,0 +1,74 @@
+//go:build !gogit
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestBranchFingerprintAndCacheRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	commitFile(t, dir, "base.txt", "base commit")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	fingerprint, err := branchFingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fingerprint["main"]; !ok {
+		t.Fatalf("expected fingerprint to contain main, got %v", fingerprint)
+	}
+
+	path, err := cachePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := shellBackend{}
+	logs, err := mergeBranchLogs(backend, "main", []Branch{{Name: "main", Current: true}}, nil, fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, err := buildCommitTree("main", []Branch{{Name: "main", Current: true}}, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := &cacheEntry{Fingerprint: fingerprint, Logs: logs, Tree: cn}
+	if err := saveCache(path, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded == nil {
+		t.Fatal("expected a cache entry to be loaded")
+	}
+	if !fingerprintsEqual(loaded.Fingerprint, fingerprint) {
+		t.Errorf("loaded fingerprint %v does not match saved %v", loaded.Fingerprint, fingerprint)
+	}
+	if loaded.Tree.Subject != cn.Subject {
+		t.Errorf("loaded tree root %q does not match saved %q", loaded.Tree.Subject, cn.Subject)
+	}
+
+	commitFile(t, dir, "second.txt", "second commit")
+	changed, err := branchFingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprintsEqual(loaded.Fingerprint, changed) {
+		t.Error("expected fingerprint to change after a new commit")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+// Hash is a commit object id. It intentionally doesn't assume a fixed
+// length so that both 40-character SHA-1 hex digests and the 64-character
+// digests of SHA-256 repositories round-trip without truncation panics.
+type Hash string
+
+// abbrevLen is how many characters Short returns. It defaults to 8 and is
+// overridden by the --abbrev flag.
+var abbrevLen = 8
+
+// Short returns the first abbrevLen characters of the hash, or the whole
+// hash if it's shorter than that.
+func (h Hash) Short() string {
+	if len(h) <= abbrevLen {
+		return string(h)
+	}
+	return string(h[:abbrevLen])
+}
+
+// Full returns the hash in its entirety.
+func (h Hash) Full() string {
+	return string(h)
+}
+
+// repoHashAlgo is the object hash algorithm the repository uses, discovered
+// once at startup so abbrevLen can be clamped to a length that's actually
+// meaningful for it.
+var repoHashAlgo string
+
+// detectHashAlgo reports which hash algorithm the repository's objects use,
+// falling back to sha1 for git versions or repositories that don't expose
+// this.
+func detectHashAlgo() string {
+	lines, err := runGitLines("rev-parse", "--show-object-format")
+	if err != nil || len(lines) != 1 {
+		return "sha1"
+	}
+	return lines[0]
+}
+
+// hashHexLen returns the full hex length of algo's object ids, falling back
+// to the SHA-1 length for anything unrecognized.
+func hashHexLen(algo string) int {
+	if algo == "sha256" {
+		return 64
+	}
+	return 40
+}
+
+// clampAbbrevLen caps abbrevLen to the full hex length of algo so --abbrev
+// (or its default) never asks Short to print more characters than the
+// repository's hashes actually have.
+func clampAbbrevLen(algo string) {
+	if max := hashHexLen(algo); abbrevLen > max {
+		abbrevLen = max
+	}
+}
@@ -1,114 +1,29 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"flag"
 	"fmt"
 	"log"
-	"os/exec"
-	"strings"
+	"runtime"
+	"sort"
 
 	"github.com/fatih/color"
-	"github.com/pkg/errors"
 	"github.com/xlab/treeprint"
 )
 
-func git(arg ...string) ([]string, error) {
-	buf := &bytes.Buffer{}
-	cmd := exec.Command("git", arg...)
-	cmd.Stdout = buf
-	if err := cmd.Run(); err != nil {
-		return nil, errors.Wrapf(err, "'git %v' failed", strings.Join(arg, " "))
-	}
-
-	lines := []string{}
-	scanner := bufio.NewScanner(buf)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	return lines, nil
-}
-
 type Branch struct {
 	Name    string
 	Current bool
 }
 
-func listBranches() ([]Branch, error) {
-	lines, err := git("branch")
-	if err != nil {
-		return nil, err
-	}
-
-	branches := make([]Branch, 0, len(lines))
-	for _, line := range lines {
-		name := line[2:]
-		if strings.HasPrefix(name, "(HEAD detached at") {
-			continue
-		}
-		branches = append(branches, Branch{
-			Name:    name,
-			Current: line[0] == '*',
-		})
-	}
-
-	return branches, nil
-}
-
-func getMainBranchName() (string, error) {
-	lines, err := git("remote")
-	if err != nil {
-		return "", err
-	}
-	if len(lines) < 1 {
-		defBranchLines, err := git("config", "--get", "init.defaultBranch")
-		if err != nil {
-			return "master", nil
-		}
-		if len(defBranchLines) != 1 {
-			return "master", nil
-		}
-		return strings.TrimSpace(defBranchLines[0]), nil
-	}
-	symRefLines, err := git("symbolic-ref", fmt.Sprintf("refs/remotes/%s/HEAD", lines[0]))
-	if err != nil {
-		return "", err
-	}
-	if len(symRefLines) != 1 {
-		return "", fmt.Errorf("expected one line for symbolic-ref for remote %v", lines[0])
-	}
-	lastSlash := strings.LastIndexByte(symRefLines[0], '/')
-	return symRefLines[0][lastSlash+1:], nil
-}
-
 type Commit struct {
-	Hash     string
+	Hash     Hash
+	Parents  []Hash
 	Subject  string
 	Author   string
 	OnMaster bool
 }
 
-func listCommits(branch string) ([]Commit, error) {
-	lines, err := git("log", "--pretty=format:%H#%an#%s", "--max-count=1000", branch, "--")
-	if err != nil {
-		return nil, err
-	}
-
-	commits := make([]Commit, len(lines))
-	for i, line := range lines {
-		parts := strings.SplitN(line, "#", 3)
-		commits[i].Hash = parts[0]
-		commits[i].Author = parts[1]
-		commits[i].Subject = parts[2]
-	}
-	return commits, nil
-}
-
 type CommitNode struct {
 	Commit
 	Branches []Branch
@@ -116,10 +31,26 @@ type CommitNode struct {
 }
 
 func (cn CommitNode) ToTree(tree treeprint.Tree) {
+	cn.addToTree(tree, make(map[Hash]bool))
+}
+
+// addToTree is ToTree's worker, threading a seen set through the recursion
+// so a commit reachable from more than one parent (e.g. two merges off a
+// shared base) is only ever rendered once, under whichever subtree reaches
+// it first, instead of appearing as a duplicate under every subtree that
+// shares it.
+func (cn CommitNode) addToTree(tree treeprint.Tree, seen map[Hash]bool) {
+	if cn.Hash != "" {
+		if seen[cn.Hash] {
+			return
+		}
+		seen[cn.Hash] = true
+	}
+
 	var childBranch treeprint.Tree
 	data := cn.Subject
 	if cn.Hash != "" {
-		data = fmt.Sprintf("%s (%s, %s)", cn.Subject, cn.Author, cn.Hash[:8])
+		data = fmt.Sprintf("%s (%s, %s)", cn.Subject, cn.Author, cn.Hash.Short())
 	}
 	if len(cn.Branches) > 0 {
 		meta := ""
@@ -137,14 +68,31 @@ func (cn CommitNode) ToTree(tree treeprint.Tree) {
 	} else {
 		childBranch = tree.AddBranch(data)
 	}
-	for i, child := range cn.Children {
-		if i == 0 && (len(cn.Branches) == 0 || cn.OnMaster) {
-			child.ToTree(tree)
-		} else if i == len(cn.Children)-1 {
-			child.ToTree(childBranch)
+
+	if len(cn.Children) > 1 {
+		// A merge commit: render every parent ancestry as its own sibling
+		// subtree instead of collapsing onto a single linear chain.
+		for _, child := range cn.Children {
+			if child.Hash != "" && seen[child.Hash] {
+				continue
+			}
+			child.addToTree(childBranch.AddBranch("┐"), seen)
+		}
+		return
+	}
+
+	for _, child := range cn.Children {
+		if child.Hash != "" && seen[child.Hash] {
+			continue
+		}
+		// A child that isn't itself on master is a branch hanging off this
+		// commit (merged or not) rather than the next step of the spine,
+		// so it always nests under this node's own branch instead of
+		// flattening alongside it.
+		if child.OnMaster && (len(cn.Branches) == 0 || cn.OnMaster) {
+			child.addToTree(tree, seen)
 		} else {
-			newBranch := childBranch.AddBranch("┐")
-			child.ToTree(newBranch)
+			child.addToTree(childBranch, seen)
 		}
 	}
 }
@@ -163,103 +111,368 @@ func (cn CommitNode) String() string {
 }
 
 func main() {
-	mainBranchName, err := getMainBranchName()
+	flag.IntVar(&abbrevLen, "abbrev", abbrevLen, "number of hash characters to display")
+	flag.IntVar(&jobs, "jobs", jobs, "number of branches to scan concurrently")
+	flag.Parse()
+
+	path, err := cachePath()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fingerprint, err := branchFingerprint()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cached, err := loadCache(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if cached != nil && fingerprintsEqual(cached.Fingerprint, fingerprint) {
+		printTree(cached.Tree)
+		return
+	}
+
+	repoHashAlgo = detectHashAlgo()
+	clampAbbrevLen(repoHashAlgo)
+
+	backend, err := newRepoBackend()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mainBranchName, err := backend.MainBranch()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	branches, err := backend.Branches()
 	if err != nil {
 		log.Fatal(err)
 	}
+	sort.Slice(branches, func(i, j int) bool { return branches[i].Name < branches[j].Name })
 
-	branches, err := listBranches()
+	logs, err := mergeBranchLogs(backend, mainBranchName, branches, cached, fingerprint)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	mCommits, err := listCommits(mainBranchName)
+	cn, err := buildCommitTree(mainBranchName, branches, logs)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	mMap := make(map[string]Commit)
+	if err := saveCache(path, &cacheEntry{Fingerprint: fingerprint, Logs: logs, Tree: cn}); err != nil {
+		log.Fatal(err)
+	}
+
+	printTree(cn)
+}
+
+func printTree(cn *CommitNode) {
+	tree := treeprint.New()
+	cn.ToTree(tree)
+	fmt.Println(tree.String())
+}
+
+// jobs is how many branches mergeBranchLogs scans concurrently. It defaults
+// to the number of CPUs and is overridden by the --jobs flag.
+var jobs = runtime.NumCPU()
+
+// mergeBranchLogs fetches the commit log for main and every branch through a
+// worker pool bounded by jobs, reusing the cached log for any branch whose
+// tip fingerprint hasn't changed instead of re-scanning it through the
+// backend. The branch-by-branch git calls are the dominant cost on repos
+// with many branches, so they run concurrently; the caller is responsible
+// for doing the graph-stitching that follows on a single goroutine so
+// output stays deterministic.
+func mergeBranchLogs(backend repoBackend, mainBranchName string, branches []Branch, cached *cacheEntry, fingerprint map[string]string) (map[string][]Commit, error) {
+	fetch := func(name string) ([]Commit, error) {
+		if cached != nil && fingerprint[name] != "" && cached.Fingerprint[name] == fingerprint[name] {
+			if commits, ok := cached.Logs[name]; ok {
+				return commits, nil
+			}
+		}
+		return backend.Log(name, 1000)
+	}
+
+	names := make([]string, 0, len(branches)+1)
+	names = append(names, mainBranchName)
+	for _, branch := range branches {
+		if branch.Name == mainBranchName {
+			continue
+		}
+		names = append(names, branch.Name)
+	}
+
+	type logResult struct {
+		name    string
+		commits []Commit
+		err     error
+	}
+
+	work := make(chan string)
+	results := make(chan logResult)
+
+	workerCount := jobs
+	if workerCount < 1 {
+		workerCount = 1
+	}
+	if workerCount > len(names) {
+		workerCount = len(names)
+	}
+
+	for i := 0; i < workerCount; i++ {
+		go func() {
+			for name := range work {
+				commits, err := fetch(name)
+				results <- logResult{name: name, commits: commits, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(work)
+		for _, name := range names {
+			work <- name
+		}
+	}()
+
+	logs := make(map[string][]Commit, len(names))
+	var firstErr error
+	for range names {
+		r := <-results
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		logs[r.name] = r.commits
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return logs, nil
+}
+
+// buildCommitTree walks every branch's ancestry back to main, stitching the
+// per-branch commit logs into a single tree rooted at the oldest commit
+// still needed. It follows every parent of every commit (not just the
+// first) so that merge commits end up with one child per incoming ancestry
+// instead of a single linearized chain.
+func buildCommitTree(mainBranchName string, branches []Branch, logs map[string][]Commit) (*CommitNode, error) {
+	mCommits, ok := logs[mainBranchName]
+	if !ok {
+		return nil, fmt.Errorf("no commit log for main branch %q", mainBranchName)
+	}
+
+	mMap := make(map[Hash]Commit)
+	allCommits := make(map[Hash]Commit)
 	for _, commit := range mCommits {
 		mMap[commit.Hash] = commit
+		allCommits[commit.Hash] = commit
+	}
+
+	cns := make(map[Hash]*CommitNode)
+	nodeFor := func(commit Commit) *CommitNode {
+		if cn, ok := cns[commit.Hash]; ok {
+			return cn
+		}
+		cn := &CommitNode{Commit: commit}
+		cns[commit.Hash] = cn
+		return cn
 	}
 
 	var mainBranch Branch
-	cns := make(map[string]*CommitNode)
-	mNeeded := make(map[string]bool)
-	var ok bool
-	var cn, lastCn *CommitNode
+	expanded := make(map[Hash]bool)
+	mNeeded := make(map[Hash]bool)
 	for _, branch := range branches {
 		if branch.Name == mainBranchName {
 			mainBranch = branch
 			continue
 		}
-		lastCn = nil
 
-		commits, err := listCommits(branch.Name)
-		if err != nil {
-			log.Fatal(err)
+		commits, ok := logs[branch.Name]
+		if !ok || len(commits) == 0 {
+			continue
+		}
+		for _, commit := range commits {
+			allCommits[commit.Hash] = commit
 		}
 
-		for i, commit := range commits {
-			if cn, ok = cns[commit.Hash]; !ok {
-				cn = &CommitNode{
-					Commit: commit,
+		tip := nodeFor(commits[0])
+		tip.Branches = append(tip.Branches, branch)
+
+		queue := []Hash{commits[0].Hash}
+		for len(queue) > 0 {
+			hash := queue[0]
+			queue = queue[1:]
+
+			if _, onMain := mMap[hash]; onMain {
+				mNeeded[hash] = true
+				// hash is where this branch forks from main. If it's not
+				// the branch's own tip, the tip is still unmerged (or has
+				// commits on top of an older merge) and can never be
+				// reached by following real parents down from main, so
+				// hang it off the fork point directly instead.
+				if hash != commits[0].Hash {
+					forkPoint := nodeFor(allCommits[hash])
+					if !hasChild(forkPoint, commits[0].Hash) {
+						forkPoint.Children = append(forkPoint.Children, tip)
+					}
 				}
-				cns[commit.Hash] = cn
-			}
-			if i == 0 {
-				cn.Branches = append(cn.Branches, branch)
+				continue
 			}
-			if lastCn != nil {
-				cn.Children = append(cn.Children, lastCn)
+			if expanded[hash] {
+				continue
 			}
-			lastCn = cn
+			expanded[hash] = true
 
-			if ok {
-				break
-			}
-			if _, ok := mMap[commit.Hash]; ok {
-				mNeeded[commit.Hash] = true
-				break
+			node := cns[hash]
+			for _, parentHash := range node.Parents {
+				parentCommit, ok := allCommits[parentHash]
+				if !ok {
+					continue
+				}
+				if _, onMain := mMap[parentHash]; onMain {
+					// Don't link forward into main's own history here: the
+					// fork point gets this branch's tip attached to it
+					// above once it's dequeued, so this node doesn't also
+					// need an edge back into main (that would make main's
+					// own ancestor a child of its own descendant).
+					queue = append(queue, parentHash)
+					continue
+				}
+				parent := nodeFor(parentCommit)
+				if !hasChild(node, parentHash) {
+					node.Children = append(node.Children, parent)
+				}
+				queue = append(queue, parentHash)
 			}
 		}
 	}
 
-	lastCn = nil
-	for i, commit := range mCommits {
-		if cn, ok = cns[commit.Hash]; !ok {
-			cn = &CommitNode{
-				Commit: commit,
-			}
-			cns[commit.Hash] = cn
+	if len(mCommits) == 0 {
+		return nil, nil
+	}
+
+	tip := nodeFor(mCommits[0])
+	tip.OnMaster = true
+	tip.Branches = append(tip.Branches, mainBranch)
+
+	// remaining counts down the branch attachment points still to be
+	// reached; once it's empty there's nothing further back on main worth
+	// rendering, so we stop expanding non-merge commits.
+	remaining := make(map[Hash]bool, len(mNeeded))
+	for hash := range mNeeded {
+		remaining[hash] = true
+	}
+
+	visited := make(map[Hash]bool)
+	queue := []Hash{mCommits[0].Hash}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if visited[hash] {
+			continue
 		}
-		cn.OnMaster = true
-		if i == 0 {
-			cn.Branches = append(cn.Branches, mainBranch)
+		visited[hash] = true
+
+		commit, ok := allCommits[hash]
+		if !ok {
+			continue
 		}
-		if lastCn != nil && !hasChild(cn, lastCn.Hash) {
-			cn.Children = append([]*CommitNode{lastCn}, cn.Children...)
+		node := nodeFor(commit)
+		node.OnMaster = true
+		delete(remaining, hash)
+
+		if len(remaining) == 0 && hash != mCommits[0].Hash && len(commit.Parents) <= 1 {
+			continue
 		}
-		_, inNeeded := mNeeded[commit.Hash]
-		if inNeeded || i == 0 {
-			lastCn = cn
-		} else if lastCn.Subject != "..." {
-			cn.Subject = "..."
-			cn.Hash = ""
-			lastCn = cn
+		if expanded[hash] {
+			continue
 		}
+		expanded[hash] = true
 
-		delete(mNeeded, commit.Hash)
-		if len(mNeeded) == 0 {
-			break
+		for _, parentHash := range commit.Parents {
+			parentCommit, ok := allCommits[parentHash]
+			if !ok {
+				continue
+			}
+			parent := nodeFor(parentCommit)
+			if !hasChild(node, parentHash) {
+				node.Children = append(node.Children, parent)
+			}
+			queue = append(queue, parentHash)
 		}
 	}
-	tree := treeprint.New()
-	cn.ToTree(tree)
-	fmt.Println(tree.String())
+
+	collapseBoringRuns(tip, mNeeded, make(map[*CommitNode]*CommitNode))
+
+	return tip, nil
+}
+
+// collapseBoringRuns folds runs of uninteresting single-parent main commits
+// (no branch attached, not a branch attachment point, not a merge) into a
+// single "..." placeholder, the same compression the tool has always
+// applied to keep long straight stretches of main out of the way. cache
+// memoizes the result per original node so a commit reachable through more
+// than one parent (e.g. two merges off a shared base) is only ever
+// collapsed once, instead of being rebuilt separately for every incoming
+// edge.
+func collapseBoringRuns(node *CommitNode, mNeeded map[Hash]bool, cache map[*CommitNode]*CommitNode) {
+	children := make([]*CommitNode, len(node.Children))
+	for i, child := range node.Children {
+		children[i] = collapseBoringChild(child, mNeeded, cache)
+	}
+	node.Children = children
+}
+
+func collapseBoringChild(node *CommitNode, mNeeded map[Hash]bool, cache map[*CommitNode]*CommitNode) *CommitNode {
+	if result, ok := cache[node]; ok {
+		return result
+	}
+	original := node
+
+	if !isBoringMainCommit(node, mNeeded) {
+		collapseBoringRuns(node, mNeeded, cache)
+		cache[original] = node
+		return node
+	}
+
+	for isBoringMainCommit(node, mNeeded) && len(node.Children) == 1 {
+		node = node.Children[0]
+	}
+
+	var result *CommitNode
+	if isBoringMainCommit(node, mNeeded) {
+		// Ran out of history while still boring (e.g. hit the first
+		// commit): collapse it away entirely rather than nesting a
+		// redundant copy of it beneath the placeholder.
+		result = &CommitNode{Commit: Commit{Subject: "...", OnMaster: true}}
+	} else {
+		collapseBoringRuns(node, mNeeded, cache)
+		result = &CommitNode{
+			Commit:   Commit{Subject: "...", OnMaster: true},
+			Children: []*CommitNode{node},
+		}
+	}
+	cache[original] = result
+	return result
+}
+
+// isBoringMainCommit reports whether a main commit carries no information
+// worth its own line: no branch points at it, no other branch needed to
+// attach here, and it isn't a fork or merge point.
+func isBoringMainCommit(node *CommitNode, mNeeded map[Hash]bool) bool {
+	return len(node.Branches) == 0 && !mNeeded[node.Hash] && len(node.Children) <= 1
 }
 
-func hasChild(node *CommitNode, hash string) bool {
+func hasChild(node *CommitNode, hash Hash) bool {
 	for _, child := range node.Children {
 		if child.Hash == hash {
 			return true
@@ -0,0 +1,245 @@
+//go:build !gogit
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/xlab/treeprint"
+)
+
+// runGit runs git in dir and fails the test on error, mirroring the plumbing
+// the shellBackend itself relies on.
+func runGit(t testing.TB, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func commitFile(t testing.TB, dir, name, subject string) {
+	t.Helper()
+	if err := os.WriteFile(dir+"/"+name, []byte(subject), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", name)
+	runGit(t, dir, "commit", "-m", subject)
+}
+
+// TestBuildCommitTreeCrissCrossMerge creates a scripted repo where two
+// branches are merged into each other (a criss-cross merge) and asserts the
+// printed tree reflects both parent ancestries of the resulting merge commit.
+func TestBuildCommitTreeCrissCrossMerge(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+
+	commitFile(t, dir, "base.txt", "base commit")
+
+	runGit(t, dir, "checkout", "-q", "-b", "feature-a")
+	commitFile(t, dir, "a.txt", "feature-a commit")
+
+	runGit(t, dir, "checkout", "-q", "-b", "feature-b", "main")
+	commitFile(t, dir, "b.txt", "feature-b commit")
+
+	runGit(t, dir, "checkout", "-q", "main")
+	runGit(t, dir, "merge", "-q", "--no-ff", "-m", "merge commit", "feature-a", "feature-b")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	backend := shellBackend{}
+	mainBranchName := "main"
+	branches, err := backend.Branches()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := mergeBranchLogs(backend, mainBranchName, branches, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, err := buildCommitTree(mainBranchName, branches, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merge := findCommitNode(cn, "merge commit")
+	if merge == nil {
+		t.Fatalf("expected to find the merge commit in the tree")
+	}
+	if len(merge.Children) < 2 {
+		t.Fatalf("expected the merge commit to have one child per parent ancestry, got %d children", len(merge.Children))
+	}
+
+	featureA := findCommitNode(merge, "feature-a commit")
+	featureB := findCommitNode(merge, "feature-b commit")
+	if featureA == nil {
+		t.Fatalf("expected to find feature-a's commit under the merge commit")
+	}
+	if featureB == nil {
+		t.Fatalf("expected to find feature-b's commit under the merge commit")
+	}
+
+	// The two branches are siblings under the merge commit, not ancestors of
+	// one another: neither subtree should contain the other's commit.
+	if containsSubject(featureA, "feature-b commit") {
+		t.Error("feature-b commit is nested under feature-a's ancestry; they should be siblings")
+	}
+	if containsSubject(featureB, "feature-a commit") {
+		t.Error("feature-a commit is nested under feature-b's ancestry; they should be siblings")
+	}
+
+	tree := treeprint.New()
+	cn.ToTree(tree)
+	output := tree.String()
+	if !strings.Contains(output, "feature-a commit") || !strings.Contains(output, "feature-b commit") {
+		t.Errorf("expected printed tree to contain both branch commits, got:\n%s", output)
+	}
+}
+
+// TestBuildCommitTreeUnmergedBranch creates a scripted repo where a branch
+// forks off main and is never merged, with a further commit landing on main
+// afterward, and asserts the unmerged branch still shows up in the tree
+// instead of being silently dropped because it's unreachable by following
+// main's own real parents.
+func TestBuildCommitTreeUnmergedBranch(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+
+	commitFile(t, dir, "base.txt", "base commit")
+
+	runGit(t, dir, "checkout", "-q", "-b", "feature")
+	commitFile(t, dir, "feature.txt", "feature commit")
+
+	runGit(t, dir, "checkout", "-q", "main")
+	commitFile(t, dir, "more.txt", "second main commit")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	backend := shellBackend{}
+	mainBranchName := "main"
+	branches, err := backend.Branches()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := mergeBranchLogs(backend, mainBranchName, branches, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, err := buildCommitTree(mainBranchName, branches, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if findCommitNode(cn, "feature commit") == nil {
+		t.Fatalf("expected the unmerged feature branch to still appear in the tree")
+	}
+
+	base := findCommitNode(cn, "base commit")
+	if base == nil {
+		t.Fatalf("expected to find the fork point (base commit) in the tree")
+	}
+	if !containsSubject(base, "feature commit") {
+		t.Error("expected the unmerged branch to be attached under its fork point")
+	}
+}
+
+// TestBuildCommitTreeSharedForkPointNotDuplicated creates a scripted repo
+// where two branches fork off the same tagged commit and are merged into
+// main separately, and asserts the shared ancestor is only rendered once
+// instead of once per subtree that reaches it.
+func TestBuildCommitTreeSharedForkPointNotDuplicated(t *testing.T) {
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+
+	commitFile(t, dir, "base.txt", "base commit")
+
+	runGit(t, dir, "checkout", "-q", "-b", "other")
+	commitFile(t, dir, "other.txt", "other commit")
+
+	runGit(t, dir, "checkout", "-q", "-b", "feature-a", "other")
+	commitFile(t, dir, "a.txt", "feature-a commit")
+
+	runGit(t, dir, "checkout", "-q", "-b", "feature-b", "other")
+	commitFile(t, dir, "b.txt", "feature-b commit")
+
+	runGit(t, dir, "checkout", "-q", "main")
+	runGit(t, dir, "merge", "-q", "--no-ff", "-m", "merge feature-a", "feature-a")
+	runGit(t, dir, "merge", "-q", "--no-ff", "-m", "merge feature-b", "feature-b")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	backend := shellBackend{}
+	mainBranchName := "main"
+	branches, err := backend.Branches()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := mergeBranchLogs(backend, mainBranchName, branches, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cn, err := buildCommitTree(mainBranchName, branches, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree := treeprint.New()
+	cn.ToTree(tree)
+	output := tree.String()
+
+	if got := strings.Count(output, "other commit"); got != 1 {
+		t.Errorf("expected the shared fork point to be rendered exactly once, got %d times:\n%s", got, output)
+	}
+}
+
+// findCommitNode searches root's subtree (inclusive) for a node with the
+// given subject, returning the first match or nil.
+func findCommitNode(root *CommitNode, subject string) *CommitNode {
+	if root.Subject == subject {
+		return root
+	}
+	for _, child := range root.Children {
+		if found := findCommitNode(child, subject); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// containsSubject reports whether root's subtree (inclusive) contains a node
+// with the given subject.
+func containsSubject(root *CommitNode, subject string) bool {
+	return findCommitNode(root, subject) != nil
+}